@@ -0,0 +1,146 @@
+"""
+Config file support for 'nlm auth': ~/.nlm/config.json (or config.toml) holds defaults for its options.
+"""
+import argparse
+import json
+import os
+from pathlib import Path
+from typing import Dict, List, Optional, Tuple
+
+
+def _comma_list(value: str) -> List[str]:
+    """argparse type for comma-separated lists; empty items are ignored"""
+    return [item.strip() for item in value.split(",") if item.strip()]
+
+# Config files with defaults for 'nlm auth' options, in order of preference; $NLM_CONFIG selects another file
+CONFIG_PATHS = [Path.home() / ".nlm" / "config.json", Path.home() / ".nlm" / "config.toml"]
+
+# Options that can also be set through environment variables, which take precedence over the config file
+_CONFIG_ENV_VARS = {
+    "profiles": ["NLM_BROWSER_PROFILE"],
+    "env_path": ["NLM_ENV_PATH"],
+    "chrome_path": ["NLM_CHROME_PATH"],
+    "proxy": ["HTTPS_PROXY", "https_proxy", "HTTP_PROXY", "http_proxy"],
+}
+
+def load_config() -> Tuple[Optional[Path], Dict]:
+    """
+    Read the 'nlm auth' config file, a JSON (or, on Python 3.11+, TOML) object of option names and values.
+    Returns the path and the settings, or (None, {}) when there is no config file.
+    """
+    configured = os.environ.get("NLM_CONFIG")
+    if configured:
+        config_path = Path(configured).expanduser()
+        if not config_path.is_file():
+            raise FileNotFoundError(f"config file not found: {config_path} ($NLM_CONFIG)")
+    else:
+        config_path = next((path for path in CONFIG_PATHS if path.is_file()), None)
+        if config_path is None:
+            return None, {}
+
+    text = config_path.read_text(encoding='utf-8')
+    if config_path.suffix == ".toml":
+        try:
+            import tomllib
+        except ImportError:
+            raise ValueError(f"{config_path}: TOML config files require Python 3.11 or later; use config.json instead")
+        try:
+            config = tomllib.loads(text)
+        except tomllib.TOMLDecodeError as e:
+            raise ValueError(f"{config_path}: {e}")
+    else:
+        try:
+            config = json.loads(text)
+        except ValueError as e:
+            raise ValueError(f"{config_path}: {e}")
+    if not isinstance(config, dict):
+        raise ValueError(f"{config_path}: expected an object mapping option names to values")
+    return config_path, config
+
+
+def _config_value(action: argparse.Action, key: str, value):
+    """Convert a config value with the option's type, like argparse converts a command-line value"""
+    if action.type is None:
+        return value
+    try:
+        return action.type(value if isinstance(value, str) else str(value))
+    except (TypeError, ValueError, argparse.ArgumentTypeError) as e:
+        raise ValueError(f"'{key}': {e}")
+
+
+def apply_config(parser: argparse.ArgumentParser, config: Dict) -> Dict:
+    """
+    Use config settings as the parser's defaults, so command-line flags still override them.
+    Keys are option names without the leading dashes ('poll-timeout' or 'poll_timeout'), plus 'profile'.
+    Returns the built-in defaults of the configured options, by dest.
+    Raises ValueError for unknown options and values of the wrong kind.
+    """
+    actions = {action.dest: action for action in parser._actions if action.dest != "help"}
+    option_dests = {option[2:]: action.dest for action in actions.values() for option in action.option_strings if option.startswith("--")}
+    defaults = {}
+    for key, value in config.items():
+        dest = "profiles" if key in ("profile", "profiles") else key.replace("-", "_")
+        # Option names whose dest differs, such as 'print'
+        dest = option_dests.get(key.replace("_", "-"), dest)
+        action = actions.get(dest)
+        if action is None:
+            raise ValueError(f"unknown option '{key}'")
+        if any(os.environ.get(name) for name in _CONFIG_ENV_VARS.get(dest, [])):
+            continue
+        if dest == "profiles":
+            value = [value] if isinstance(value, str) else value
+            if not isinstance(value, list) or not all(isinstance(item, str) for item in value):
+                raise ValueError(f"'{key}' must be a profile name or a list of profile names")
+        elif action.nargs == 0:
+            if not isinstance(value, bool):
+                raise ValueError(f"'{key}' must be true or false")
+        elif value is True and action.nargs == "?":
+            # e.g. "profile-cache": true for the default directory
+            value = action.const
+        elif isinstance(action, argparse._AppendAction):
+            # Repeatable options take a list, and a single value as a list of one; command-line values are added to it
+            value = value if isinstance(value, list) else [value]
+            if not all(isinstance(item, (str, int, float)) and not isinstance(item, bool) for item in value):
+                raise ValueError(f"'{key}' must be a string or a list of strings")
+            value = [_config_value(action, key, item) for item in value]
+        elif isinstance(value, list) and action.type is _comma_list:
+            if not all(isinstance(item, str) for item in value):
+                raise ValueError(f"'{key}' must be a string or a list of strings")
+            value = _config_value(action, key, ",".join(value))
+        elif isinstance(value, bool) or not isinstance(value, (str, int, float)):
+            raise ValueError(f"'{key}' must be a string or a number")
+        else:
+            value = _config_value(action, key, value)
+        if action.choices and value not in action.choices:
+            raise ValueError(f"'{key}' must be one of: {', '.join(map(str, action.choices))}")
+        defaults[dest] = value
+    # argparse gives an empty list, not the None default, when no profile is named
+    builtin = {dest: [] if dest == "profiles" else parser.get_default(dest) for dest in defaults}
+    parser.set_defaults(**defaults)
+    return builtin
+
+
+# Options that cannot be combined; when the command line gives one of a pair, a config setting for the other is dropped
+EXCLUSIVE_OPTIONS = [
+    ("print_value", "output"), ("print_value", "output_socket"), ("print_value", "format"), ("print_value", "out"),
+    ("print_value", "emit"), ("print_value", "json_compact"), ("output_socket", "output"), ("output_socket", "emit"),
+    ("out", "format"), ("out", "output"), ("out", "output_socket"), ("out", "tee"), ("out", "append"),
+    ("emit", "format"), ("append", "format"), ("json_compact", "format"),
+    ("profiles", "profile_directory"), ("profiles", "profiles_file"), ("profiles", "interactive"),
+    ("profile_directory", "profiles_file"), ("profile_directory", "interactive"), ("profiles_file", "interactive"),
+    ("interactive", "remote_debug_port"), ("compare", "env_template"),
+]
+
+
+def explicit_dests(parser: argparse.ArgumentParser, args: List[str]) -> set:
+    """Return the dests of the options given on the command line, ignoring the configured defaults"""
+    unset = object()
+    # Defaults are only filled in for attributes the namespace lacks; appending needs None to start from
+    namespace = argparse.Namespace(**{action.dest: None if isinstance(action, argparse._AppendAction) else unset
+                                      for action in parser._actions if action.dest != "help"})
+    given = parser.parse_args(args, namespace)
+    explicit = {dest for dest, value in vars(given).items() if value is not unset and value is not None}
+    # The profile positional is always filled in, with the default when no profile is named
+    if not given.profiles or given.profiles == parser.get_default("profiles"):
+        explicit.discard("profiles")
+    return explicit
@@ -0,0 +1,53 @@
+"""
+Env file encryption for 'nlm auth --encrypt': AES-256-GCM under a key derived from a passphrase with
+PBKDF2-SHA256. Needs the 'cryptography' package from the crypto extra.
+"""
+import base64
+import hashlib
+import os
+
+# First line of an env file written with --encrypt; the rest is base64(salt | nonce | AES-GCM ciphertext)
+ENCRYPTED_ENV_HEADER = "# nlm encrypted env v1"
+
+class EnvDecryptionError(ValueError):
+    """Raised when an encrypted env file cannot be decrypted."""
+    pass
+
+# PBKDF2-HMAC-SHA256 rounds deriving the AES-256 key from the passphrase
+_ENV_KDF_ITERATIONS = 600_000
+
+
+def _env_cipher_key(passphrase: str, salt: bytes) -> bytes:
+    return hashlib.pbkdf2_hmac("sha256", passphrase.encode("utf-8"), salt, _ENV_KDF_ITERATIONS)
+
+def _aesgcm():
+    try:
+        from cryptography.hazmat.primitives.ciphers.aead import AESGCM
+    except ImportError:
+        raise EnvDecryptionError("encrypted env files need the 'cryptography' package. Install it with: uv pip install -e '.[crypto]'")
+    return AESGCM
+
+def encrypt_env_content(content: str, passphrase: str) -> str:
+    """Encrypt env file content with AES-GCM under a key derived from passphrase"""
+    AESGCM = _aesgcm()
+    salt, nonce = os.urandom(16), os.urandom(12)
+    ciphertext = AESGCM(_env_cipher_key(passphrase, salt)).encrypt(nonce, content.encode("utf-8"), ENCRYPTED_ENV_HEADER.encode("utf-8"))
+    return f"{ENCRYPTED_ENV_HEADER}\n{base64.b64encode(salt + nonce + ciphertext).decode('ascii')}\n"
+
+def decrypt_env_content(content: str, passphrase: str) -> str:
+    """Reverse encrypt_env_content; raises EnvDecryptionError for a wrong passphrase or damaged content"""
+    AESGCM = _aesgcm()
+    from cryptography.exceptions import InvalidTag
+    _, _, blob = content.partition("\n")
+    try:
+        data = base64.b64decode("".join(blob.split()), validate=True)
+    except ValueError:
+        raise EnvDecryptionError("encrypted env file is damaged")
+    if len(data) < 16 + 12 + 16:
+        raise EnvDecryptionError("encrypted env file is damaged")
+    salt, nonce, ciphertext = data[:16], data[16:28], data[28:]
+    try:
+        plaintext = AESGCM(_env_cipher_key(passphrase, salt)).decrypt(nonce, ciphertext, ENCRYPTED_ENV_HEADER.encode("utf-8"))
+    except InvalidTag:
+        raise EnvDecryptionError("wrong passphrase for the encrypted env file, or the file was modified")
+    return plaintext.decode("utf-8")
@@ -0,0 +1,377 @@
+"""
+The env file of 'nlm auth' (~/.nlm/env by default): reading, locking, comparing and writing the NLM_* variables,
+optionally encrypted or rendered from --env-template.
+"""
+import os
+import platform
+import string
+import time
+from contextlib import contextmanager
+from datetime import datetime, timezone
+from pathlib import Path
+from typing import Dict, List, Optional, Tuple
+
+from .auth_crypto import ENCRYPTED_ENV_HEADER, EnvDecryptionError, decrypt_env_content, encrypt_env_content
+from .auth_log import _log, _now_rfc3339
+
+
+def _parse_env_line(line: str) -> Optional[Tuple[str, str, str]]:
+    """Split an env file line into (key, unquoted value, trailing comment). Returns None for blank and comment lines."""
+    line = line.strip()
+    if not line or line.startswith("#") or "=" not in line:
+        return None
+
+    key, rest = line.split("=", 1)
+    key = key.strip()
+    if key.startswith("export "):
+        key = key[len("export "):].strip()
+    rest = rest.strip()
+
+    # Handle quoted values; anything after the closing quote is a comment
+    if rest[:1] in ('"', "'"):
+        end = rest.find(rest[0], 1)
+        if end != -1:
+            return key, rest[1:end], rest[end + 1:].strip()
+
+    comment_start = rest.find(" #")
+    if comment_start != -1:
+        return key, rest[:comment_start].strip(), rest[comment_start:].strip()
+    return key, rest, ""
+
+
+# Where the env file lived before $XDG_CONFIG_HOME was honored; still read if the new file does not exist
+_LEGACY_ENV_PATH = Path.home() / ".nlm" / "env"
+
+def get_env_path(env_path: Optional[str] = None) -> Path:
+    """
+    Return the env file path: the given path, then $NLM_ENV_PATH, then on Linux and BSD $XDG_CONFIG_HOME/nlm/env,
+    then ~/.nlm/env.
+    """
+    env_path = env_path or os.environ.get("NLM_ENV_PATH")
+    if env_path:
+        return Path(env_path).expanduser()
+    # XDG is a Linux/BSD convention; macOS and Windows keep the platform default even when it is set
+    xdg_config_home = os.environ.get("XDG_CONFIG_HOME") if platform.system() not in ("Darwin", "Windows") else None
+    if xdg_config_home:
+        return Path(xdg_config_home).expanduser() / "nlm" / "env"
+    return _LEGACY_ENV_PATH
+
+
+ENV_PASSPHRASE_VAR = "NLM_ENV_PASSPHRASE"
+
+_encrypt_env = False
+_env_passphrase: Optional[str] = None
+
+def set_env_encryption(encrypt: bool, passphrase: Optional[str] = None) -> None:
+    """Encrypt env files written from now on, and use passphrase (else $NLM_ENV_PASSPHRASE) to read encrypted ones"""
+    global _encrypt_env, _env_passphrase
+    _encrypt_env = encrypt
+    _env_passphrase = passphrase
+
+def _current_env_passphrase() -> str:
+    return _env_passphrase or os.environ.get(ENV_PASSPHRASE_VAR, "")
+
+def _is_encrypted_env(text: str) -> bool:
+    return text.startswith(ENCRYPTED_ENV_HEADER + "\n")
+
+def _read_env_text(env_file: Path) -> str:
+    """Read an env file, decrypting it if it was written with --encrypt"""
+    text = env_file.read_text(encoding='utf-8')
+    if not _is_encrypted_env(text):
+        return text
+    passphrase = _current_env_passphrase()
+    if not passphrase:
+        raise EnvDecryptionError(f"{env_file} is encrypted; pass --passphrase or set {ENV_PASSPHRASE_VAR}")
+    return decrypt_env_content(text, passphrase)
+
+def _write_private_file(path: Path, content: str, append: bool = False) -> Optional[Exception]:
+    """
+    Write (or append) content to a file readable by the owner only, since it holds credentials. A write goes to a
+    temporary file created with mode 0600 that then replaces the file, so no one sees it partly written.
+    """
+    try:
+        if append:
+            fd = os.open(path, os.O_WRONLY | os.O_CREAT | os.O_APPEND, 0o600)
+            with os.fdopen(fd, "w", encoding="utf-8") as f:
+                f.write(content)
+            return None
+        temp_path = path.with_name(f".{path.name}.{os.getpid()}.tmp")
+        fd = os.open(temp_path, os.O_WRONLY | os.O_CREAT | os.O_TRUNC, 0o600)
+        try:
+            with os.fdopen(fd, "w", encoding="utf-8") as f:
+                f.write(content)
+            os.replace(temp_path, path)
+        except OSError:
+            temp_path.unlink(missing_ok=True)
+            raise
+    except OSError as e:
+        return Exception(f"Failed to write {path}: {e}")
+    return None
+
+
+def _make_private_dirs(directory: Path) -> None:
+    """Create directory and its missing parents with mode 0700; mkdir(parents=True) would only give it to the last one"""
+    missing = []
+    while not directory.exists():
+        missing.append(directory)
+        directory = directory.parent
+    for path in reversed(missing):
+        path.mkdir(mode=0o700, exist_ok=True)
+
+
+def _write_env_text(env_file: Path, content: str, encrypt: bool = False) -> None:
+    """Write an env file, encrypted when --encrypt is in effect or encrypt is set (the file already was)"""
+    if _encrypt_env or encrypt:
+        passphrase = _current_env_passphrase()
+        if not passphrase:
+            raise EnvDecryptionError(f"encrypting {env_file} needs a passphrase; pass --passphrase or set {ENV_PASSPHRASE_VAR}")
+        content = encrypt_env_content(content, passphrase)
+    err = _write_private_file(env_file, content)
+    if err:
+        raise err
+
+
+def load_stored_env(env_path: Optional[str] = None) -> Optional[Tuple[str, str]]:
+    """Load stored authentication information from the env file (~/.nlm/env by default)."""
+    env_file = _stored_env_file(env_path)
+    if not env_file.exists():
+        return None, None
+
+    auth_token = None
+    cookies = None
+
+    try:
+        for line in _read_env_text(env_file).splitlines():
+            parsed = _parse_env_line(line)
+            if not parsed:
+                continue
+
+            key, value, _ = parsed
+            if key == "NLM_AUTH_TOKEN":
+                auth_token = value
+            elif key == "NLM_COOKIES":
+                cookies = value
+    except Exception as e:
+        _log(f"Error reading env file {env_file}: {e}", "error")
+        return None, None
+
+    if auth_token and cookies:
+        return auth_token, cookies
+    else:
+        return None, None
+
+
+def _stored_env_file(env_path: Optional[str] = None) -> Path:
+    """Return the env file to read stored credentials from"""
+    env_file = get_env_path(env_path)
+    if not env_file.exists() and not env_path and not os.environ.get("NLM_ENV_PATH"):
+        # Credentials saved before $XDG_CONFIG_HOME was set
+        env_file = _LEGACY_ENV_PATH
+    return env_file
+
+
+def stored_extracted_at(env_path: Optional[str] = None) -> str:
+    """Return NLM_EXTRACTED_AT from the env file, or an empty string if it is not recorded"""
+    extracted_at = ""
+    try:
+        for line in _read_env_text(_stored_env_file(env_path)).splitlines():
+            parsed = _parse_env_line(line)
+            if parsed and parsed[0] == "NLM_EXTRACTED_AT":
+                extracted_at = parsed[1]
+    except (OSError, ValueError):
+        return ""
+    return extracted_at
+
+
+def stored_credentials_age(env_path: Optional[str] = None) -> Optional[float]:
+    """
+    Return the age in seconds of the credentials in the env file, or None if there are none.
+    Uses NLM_EXTRACTED_AT when present and the file's modification time otherwise.
+    """
+    env_file = _stored_env_file(env_path)
+    if not env_file.exists():
+        return None
+
+    try:
+        extracted_at = stored_extracted_at(env_path)
+        if extracted_at:
+            written = datetime.fromisoformat(extracted_at.replace("Z", "+00:00"))
+            return (datetime.now(timezone.utc) - written).total_seconds()
+        return time.time() - env_file.stat().st_mtime
+    except (OSError, ValueError):
+        return None
+
+
+class EnvFileLockedError(Exception):
+    """Raised when another process keeps the env file locked."""
+    pass
+
+
+# Seconds to wait for another process writing the env file
+_ENV_LOCK_TIMEOUT = 10.0
+
+def _lock_file(lock_file) -> None:
+    """Take a non-blocking exclusive lock, raising OSError if it is held elsewhere"""
+    if os.name == "nt":
+        import msvcrt
+        lock_file.seek(0)
+        msvcrt.locking(lock_file.fileno(), msvcrt.LK_NBLCK, 1)
+    else:
+        import fcntl
+        fcntl.flock(lock_file.fileno(), fcntl.LOCK_EX | fcntl.LOCK_NB)
+
+def _unlock_file(lock_file) -> None:
+    """Release a lock taken by _lock_file"""
+    if os.name == "nt":
+        import msvcrt
+        lock_file.seek(0)
+        msvcrt.locking(lock_file.fileno(), msvcrt.LK_UNLCK, 1)
+    else:
+        import fcntl
+        fcntl.flock(lock_file.fileno(), fcntl.LOCK_UN)
+
+@contextmanager
+def _env_file_lock(env_file: Path, timeout: float = _ENV_LOCK_TIMEOUT):
+    """Hold an exclusive lock on '<env file>.lock' so concurrent runs don't interleave their writes"""
+    lock_path = env_file.with_name(env_file.name + ".lock")
+    with open(lock_path, "a+") as lock_file:
+        deadline = time.monotonic() + timeout
+        while True:
+            try:
+                _lock_file(lock_file)
+                break
+            except OSError:
+                if time.monotonic() >= deadline:
+                    raise EnvFileLockedError(f"another nlm auth is running and writing {env_file}; try again later")
+                time.sleep(0.1)
+        try:
+            yield
+        finally:
+            _unlock_file(lock_file)
+
+
+def _cookie_values(cookies: str) -> Dict[str, str]:
+    """Map cookie names to values in a 'name=value; ...' string"""
+    pairs = [pair.strip().split("=", 1) for pair in cookies.split(";") if "=" in pair]
+    return {name: value for name, value in pairs}
+
+def compare_with_stored(auth_token: str, cookies: str, env_path: Optional[str] = None) -> List[str]:
+    """
+    Describe how credentials differ from those stored in the env file, e.g.
+    ["token", "cookies changed: SID"]. Returns an empty list if they are identical.
+    """
+    stored_token, stored_cookies = load_stored_env(env_path) or (None, None)
+    if not stored_token:
+        return ["nothing stored yet"]
+
+    changes = []
+    if auth_token != stored_token:
+        changes.append("token")
+    old, new = _cookie_values(stored_cookies), _cookie_values(cookies)
+    for label, names in (
+        ("cookies added", new.keys() - old.keys()),
+        ("cookies removed", old.keys() - new.keys()),
+        ("cookies changed", {name for name in new.keys() & old.keys() if new[name] != old[name]}),
+    ):
+        if names:
+            changes.append(f"{label}: {', '.join(sorted(names))}")
+    return changes
+
+def env_file_rotated(auth_token: str, cookies: str, profile_name: str = "Default", env_path: Optional[str] = None,
+                     env_template: Optional[str] = None, email: str = "") -> bool:
+    """Return True if saving the credentials would change the env file, i.e. they were rotated"""
+    if not env_template:
+        return bool(compare_with_stored(auth_token, cookies, env_path))
+    # A rendered template has no NLM_* variables to compare, so compare the whole file
+    content = render_env_template(env_template, auth_token, cookies, profile_name, email)
+    try:
+        return _read_env_text(get_env_path(env_path)) != content
+    except (OSError, ValueError):
+        return True
+
+# Placeholders available in an --env-template file
+ENV_TEMPLATE_FIELDS = ["token", "cookies", "profile", "email"]
+
+def render_env_template(template_path: str, auth_token: str, cookies: str, profile_name: str = "Default", email: str = "") -> str:
+    """
+    Render an env file template. Templates use string.Template syntax with the
+    placeholders $token, $cookies, $profile and $email, e.g. NOTEBOOKLM_TOKEN="$token".
+    """
+    path = Path(template_path).expanduser()
+    try:
+        template = string.Template(path.read_text(encoding='utf-8'))
+        return template.substitute(token=auth_token, cookies=cookies, profile=profile_name, email=email)
+    except KeyError as e:
+        raise ValueError(f"unknown placeholder ${e.args[0]} in env template {path}; use {', '.join('$' + f for f in ENV_TEMPLATE_FIELDS)}")
+    except ValueError as e:
+        raise ValueError(f"invalid env template {path}: {e}")
+
+
+def save_auth_to_env(auth_token: str, cookies: str, profile_name: str = "Default", env_path: Optional[str] = None,
+                     env_template: Optional[str] = None, email: str = "", extracted_at: str = "") -> None:
+    """
+    Save authentication information to env file (~/.nlm/env by default).
+    With env_template the file is replaced by the rendered template instead.
+    NLM_EXTRACTED_AT is set to extracted_at, or to the current time if it is empty.
+    """
+    env_file = get_env_path(env_path)
+    _make_private_dirs(env_file.parent)
+
+    if env_template:
+        content = render_env_template(env_template, auth_token, cookies, profile_name, email)
+        with _env_file_lock(env_file):
+            _write_env_text(env_file, content)
+        return
+
+    updates = {
+        "NLM_COOKIES": f'"{cookies}"',
+        "NLM_AUTH_TOKEN": f'"{auth_token}"',
+        "NLM_BROWSER_PROFILE": f'"{profile_name}"',
+        "NLM_EXTRACTED_AT": f'"{extracted_at or _now_rfc3339()}"',
+    }
+
+    with _env_file_lock(env_file):
+        _update_env_file(env_file, updates)
+
+
+def _update_env_file(env_file: Path, updates: Dict[str, str]) -> None:
+    """Set the given keys in the env file in place, keeping every other line as is."""
+    existing_lines = []
+    was_encrypted = False
+    if env_file.exists():
+        try:
+            existing_text = env_file.read_text(encoding='utf-8')
+            # Keep an encrypted file encrypted; failing to decrypt it must not replace it with fewer lines
+            was_encrypted = _is_encrypted_env(existing_text)
+            existing_lines = _read_env_text(env_file).splitlines()
+        except EnvDecryptionError:
+            raise
+        except Exception as e:
+            _log(f"Warning: Could not read existing env file {env_file}: {e}", "warning")
+
+    # Update the NLM keys in place, keeping every other line (including comments) as is
+    content_lines = []
+    written = set()
+    for line in existing_lines:
+        parsed = _parse_env_line(line)
+        if not parsed or parsed[0] not in updates:
+            content_lines.append(line)
+            continue
+
+        key, _, comment = parsed
+        if key in written:
+            # Drop duplicate assignments so the file has a single value per key
+            continue
+        prefix = "export " if line.lstrip().startswith("export ") else ""
+        content_lines.append(f"{prefix}{key}={updates[key]}" + (f" {comment}" if comment else ""))
+        written.add(key)
+
+    for key, value in updates.items():
+        if key not in written:
+            content_lines.append(f"{key}={value}")
+
+    try:
+        _write_env_text(env_file, "\n".join(content_lines) + "\n", was_encrypted)
+    except Exception as e:
+         _log(f"Error writing to env file {env_file}: {e}", "error")
+         raise
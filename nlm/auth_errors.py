@@ -0,0 +1,77 @@
+"""
+Errors raised by 'nlm auth' and the exit codes they map to.
+"""
+
+class LoginRequiredError(Exception):
+    """Raised when the service redirects to a login or consent page."""
+    pass
+
+
+class BrowserLaunchError(Exception):
+    """Raised when the browser cannot be started."""
+    pass
+
+
+class KeyringLockedError(Exception):
+    """Raised when cookies encrypted with the desktop keyring could not be decrypted."""
+    pass
+
+
+class PartialAuthError(Exception):
+    """Returned when --partial produced only the token or only the cookies."""
+    pass
+
+
+class StaleCredentialsError(Exception):
+    """Returned when extraction failed and the stored credentials were used instead; the extraction error is its cause."""
+    pass
+
+
+class AuthInterruptedError(Exception):
+    """Raised when the user interrupts 'nlm auth' with Ctrl-C."""
+    pass
+
+
+class OverallTimeoutError(TimeoutError):
+    """Raised when the whole authentication run exceeds --timeout-overall."""
+    pass
+
+
+# Exit codes of 'nlm auth'
+EXIT_OK = 0
+EXIT_UNKNOWN = 1
+EXIT_PROFILE_NOT_FOUND = 2
+EXIT_TIMEOUT = 3
+EXIT_LOGIN_REQUIRED = 4
+EXIT_BROWSER_LAUNCH_FAILED = 5
+EXIT_PARTIAL = 6
+EXIT_INTERRUPTED = 130
+
+# Names of the exit codes, used as the error category in --error-webhook reports
+_ERROR_CATEGORIES = {
+    EXIT_UNKNOWN: "unknown",
+    EXIT_PROFILE_NOT_FOUND: "profile_not_found",
+    EXIT_TIMEOUT: "timeout",
+    EXIT_LOGIN_REQUIRED: "login_required",
+    EXIT_BROWSER_LAUNCH_FAILED: "browser_launch_failed",
+    EXIT_PARTIAL: "partial",
+    EXIT_INTERRUPTED: "interrupted",
+}
+
+def exit_code_for_error(err: BaseException) -> int:
+    """Map an authentication error (or its cause) to an exit code"""
+    while err is not None:
+        if isinstance(err, AuthInterruptedError):
+            return EXIT_INTERRUPTED
+        if isinstance(err, PartialAuthError):
+            return EXIT_PARTIAL
+        if isinstance(err, LoginRequiredError):
+            return EXIT_LOGIN_REQUIRED
+        if isinstance(err, BrowserLaunchError):
+            return EXIT_BROWSER_LAUNCH_FAILED
+        if isinstance(err, FileNotFoundError):
+            return EXIT_PROFILE_NOT_FOUND
+        if isinstance(err, TimeoutError):
+            return EXIT_TIMEOUT
+        err = err.__cause__
+    return EXIT_UNKNOWN
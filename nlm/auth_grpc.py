@@ -0,0 +1,183 @@
+"""
+gRPC service for 'nlm auth --grpc-listen', defined in auth.proto.
+
+The messages are flat, so they are encoded and decoded here directly in the protobuf
+wire format; the server only needs grpcio, not generated code.
+"""
+import argparse
+import struct
+import time
+from concurrent.futures import ThreadPoolExecutor, TimeoutError as FutureTimeoutError
+from dataclasses import replace
+from typing import Callable, Dict, Optional, Tuple
+
+from .auth_errors import BrowserLaunchError, LoginRequiredError
+from .auth_log import _log, _now_rfc3339
+
+SERVICE_NAME = "nlm.auth.v1.AuthService"
+
+# AuthResult fields in the order of their field numbers in auth.proto
+RESULT_FIELDS = ["auth_token", "cookies", "profile_name", "browser", "version", "extracted_at",
+                 "account_email", "authorization", "at_token"]
+
+_WIRE_VARINT, _WIRE_FIXED64, _WIRE_LENGTH, _WIRE_FIXED32 = 0, 1, 2, 5
+
+# Seconds given to running extractions to finish when the server is stopped
+_SHUTDOWN_GRACE = 5.0
+
+
+def _encode_varint(value: int) -> bytes:
+    """Encode a non-negative integer as a protobuf varint"""
+    out = bytearray()
+    while True:
+        byte = value & 0x7F
+        value >>= 7
+        if value:
+            out.append(byte | 0x80)
+        else:
+            out.append(byte)
+            return bytes(out)
+
+
+def _decode_varint(data: bytes, pos: int) -> Tuple[int, int]:
+    """Decode a varint at pos, returning (value, position after it)"""
+    value = 0
+    shift = 0
+    while True:
+        if pos >= len(data):
+            raise ValueError("truncated varint")
+        byte = data[pos]
+        pos += 1
+        value |= (byte & 0x7F) << shift
+        if not byte & 0x80:
+            return value, pos
+        shift += 7
+
+
+def encode_auth_result(result) -> bytes:
+    """Serialize an AuthResult as the AuthResult message; empty strings are omitted as in proto3"""
+    out = bytearray()
+    for number, name in enumerate(RESULT_FIELDS, 1):
+        value = getattr(result, name).encode("utf-8")
+        if value:
+            out += _encode_varint(number << 3 | _WIRE_LENGTH) + _encode_varint(len(value)) + value
+    return bytes(out)
+
+
+def decode_extract_request(data: bytes) -> Dict:
+    """Parse an ExtractAuthRequest message into a dict, skipping unknown fields"""
+    request = {"profile": "", "browser": "", "timeout_seconds": 0.0}
+    pos = 0
+    while pos < len(data):
+        key, pos = _decode_varint(data, pos)
+        number, wire_type = key >> 3, key & 0x7
+        if wire_type == _WIRE_VARINT:
+            _, pos = _decode_varint(data, pos)
+        elif wire_type == _WIRE_FIXED64:
+            if pos + 8 > len(data):
+                raise ValueError("truncated message")
+            if number == 3:
+                request["timeout_seconds"] = struct.unpack("<d", data[pos:pos + 8])[0]
+            pos += 8
+        elif wire_type == _WIRE_LENGTH:
+            length, pos = _decode_varint(data, pos)
+            if number in (1, 2):
+                request["profile" if number == 1 else "browser"] = data[pos:pos + length].decode("utf-8")
+            pos += length
+        elif wire_type == _WIRE_FIXED32:
+            pos += 4
+        else:
+            raise ValueError(f"unsupported wire type {wire_type}")
+        if pos > len(data):
+            raise ValueError("truncated message")
+    return request
+
+
+def _status_for_error(grpc, err: BaseException):
+    """Map an extraction error (or its cause) to a gRPC status code"""
+    while err is not None:
+        if isinstance(err, LoginRequiredError):
+            return grpc.StatusCode.UNAUTHENTICATED
+        if isinstance(err, FileNotFoundError):
+            return grpc.StatusCode.NOT_FOUND
+        if isinstance(err, TimeoutError):
+            return grpc.StatusCode.DEADLINE_EXCEEDED
+        if isinstance(err, BrowserLaunchError):
+            return grpc.StatusCode.UNAVAILABLE
+        err = err.__cause__
+    return grpc.StatusCode.INTERNAL
+
+
+def serve_grpc(options: argparse.Namespace, base_options, extract_auth: Callable, browser_names: Dict[str, str]) -> Optional[Exception]:
+    """
+    Serve ExtractAuth on options.grpc_listen until interrupted. Each request runs extract_auth with
+    base_options, the AuthOptions of the command line, overriding the profile and browser when the
+    request names them. browser_names maps each supported browser to its display name.
+    """
+    try:
+        import grpc
+    except ImportError:
+        return ImportError("--grpc-listen needs grpcio from the grpc extra. Install it with: uv pip install -e '.[grpc]'")
+
+    default_browser, default_profile = base_options.browser, base_options.profile_name
+    # Extractions are bounded separately from the RPC threads so queued requests still honour their timeouts
+    extractions = ThreadPoolExecutor(max_workers=options.jobs, thread_name_prefix="nlm-extract")
+
+    def extract(request: Dict, context) -> bytes:
+        browser = request["browser"] or default_browser
+        if browser not in browser_names:
+            context.abort(grpc.StatusCode.INVALID_ARGUMENT, f"unsupported browser '{browser}' (expected one of: {', '.join(browser_names)})")
+        auth_options = replace(base_options, browser=browser, profile_name=request["profile"] or default_profile)
+
+        # Mirror --timeout-overall, letting the request and the client deadline shorten it
+        budgets = [seconds for seconds in (options.timeout_overall, request["timeout_seconds"], context.time_remaining()) if seconds and seconds > 0]
+        timeout = min(budgets) if budgets else None
+
+        browser_name = browser_names[browser]
+        _log(f"nlm: ExtractAuth request for {browser_name} profile '{auth_options.profile_name}' from {context.peer()}",
+             profile=auth_options.profile_name, browser=browser, peer=context.peer())
+        started = time.monotonic()
+        future = extractions.submit(extract_auth, auth_options)
+        try:
+            result = future.result(timeout=timeout)
+        except FutureTimeoutError:
+            # A queued extraction is dropped. One that already started cannot be interrupted from here, so it
+            # keeps its browser and its --jobs slot until its navigation and polling timeouts end it
+            future.cancel()
+            _log(f"nlm: ExtractAuth for profile '{auth_options.profile_name}' did not finish within {timeout:g} seconds", "error", profile=auth_options.profile_name)
+            context.abort(grpc.StatusCode.DEADLINE_EXCEEDED, f"authentication did not finish within {timeout:g} seconds")
+        except Exception as e:
+            _log(f"nlm: ExtractAuth for profile '{auth_options.profile_name}' failed: {e}", "error", profile=auth_options.profile_name)
+            context.abort(_status_for_error(grpc, e), str(e))
+        if not result.extracted_at:
+            result.extracted_at = _now_rfc3339()
+        _log(f"nlm: ExtractAuth for profile '{auth_options.profile_name}' succeeded in {time.monotonic() - started:.1f}s",
+             profile=auth_options.profile_name, account_email=result.account_email)
+        return encode_auth_result(result)
+
+    handler = grpc.method_handlers_generic_handler(SERVICE_NAME, {
+        "ExtractAuth": grpc.unary_unary_rpc_method_handler(
+            extract, request_deserializer=decode_extract_request, response_serializer=lambda data: data),
+    })
+    server = grpc.server(ThreadPoolExecutor(max_workers=options.jobs + 4, thread_name_prefix="nlm-grpc"))
+    server.add_generic_rpc_handlers((handler,))
+    host, port = options.grpc_listen
+    try:
+        bound = server.add_insecure_port(f"{f'[{host}]' if ':' in host else host or '[::]'}:{port}")
+    except RuntimeError as e:
+        return OSError(f"Failed to listen on {host or '*'}:{port}: {e}")
+    if not bound:
+        return OSError(f"Failed to listen on {host or '*'}:{port}")
+
+    server.start()
+    if not host or host in ("0.0.0.0", "::"):
+        _log("Warning: The gRPC service hands out credentials without authentication on all interfaces; bind it to 127.0.0.1 or a private network", "warning")
+    _log(f"nlm: Serving {SERVICE_NAME}/ExtractAuth on {host or '*'}:{port} (Ctrl-C to stop)", port=port)
+    try:
+        server.wait_for_termination()
+    except KeyboardInterrupt:
+        _log("nlm: Stopping the gRPC service")
+        server.stop(_SHUTDOWN_GRACE).wait()
+    finally:
+        extractions.shutdown(wait=False)
+    return None
@@ -0,0 +1,75 @@
+"""
+Progress and error messages of 'nlm auth' on stderr, as text or JSON lines, and --progress events.
+"""
+import json
+import sys
+import traceback
+from datetime import datetime, timezone
+from typing import Optional
+
+# Format of progress and error messages: "text" (human friendly) or "json" (one object per line)
+LOG_FORMATS = ["text", "json"]
+_log_format = "text"
+
+def set_log_format(fmt: str) -> None:
+    """Select how progress and error messages are written to stderr"""
+    global _log_format
+    if fmt not in LOG_FORMATS:
+        raise ValueError(f"Unknown log format: {fmt}")
+    _log_format = fmt
+
+# When set, only error messages are written
+_quiet = False
+
+def set_quiet(quiet: bool) -> None:
+    """Suppress progress, debug and warning messages, keeping errors"""
+    global _quiet
+    _quiet = quiet
+
+def _log(msg: str, level: str = "info", **fields) -> None:
+    """Write a progress or error message to stderr, with optional structured fields for JSON output"""
+    if _quiet and level != "error":
+        return
+    if _log_format == "json":
+        record = {"level": level, "msg": msg}
+        record.update(fields)
+        print(json.dumps(record), file=sys.stderr)
+    else:
+        print(msg, file=sys.stderr)
+
+# Machine-readable progress modes selectable with --progress
+PROGRESS_MODES = ["json"]
+_progress_mode = None
+
+def set_progress(mode: Optional[str]) -> None:
+    """Emit progress events in the given mode on stderr, or none when mode is None"""
+    global _progress_mode
+    if mode is not None and mode not in PROGRESS_MODES:
+        raise ValueError(f"Unknown progress mode: {mode}")
+    _progress_mode = mode
+
+def _progress(event: str, options=None, **fields) -> None:
+    """
+    Write a progress event (started, copying_profile, launching_browser, navigating, polling,
+    success or failed) as one JSON line on stderr, for front-ends; does nothing unless --progress is set.
+    """
+    if _progress_mode != "json":
+        return
+    record = {"event": event, "time": _now_rfc3339()}
+    if options is not None:
+        record.update(profile=options.profile_name, browser=options.browser)
+    record.update(fields)
+    print(json.dumps(record), file=sys.stderr, flush=True)
+
+def _log_traceback() -> None:
+    """Write the traceback of the exception being handled"""
+    if _quiet:
+        return
+    if _log_format == "json":
+        _log("Traceback", "debug", traceback=traceback.format_exc())
+    else:
+        traceback.print_exc()
+
+def _now_rfc3339() -> str:
+    """Return the current UTC time as an RFC 3339 timestamp"""
+    return datetime.now(timezone.utc).isoformat(timespec="seconds").replace("+00:00", "Z")
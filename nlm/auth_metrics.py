@@ -0,0 +1,35 @@
+"""
+Prometheus metrics for 'nlm auth --metrics-file', written for node_exporter's textfile collector.
+"""
+import os
+from pathlib import Path
+from typing import Optional
+
+
+def write_metrics_file(path: str, success: bool, duration: float, cookie_count: int, profile_name: str = "Default",
+                       fallback: bool = False) -> Optional[Exception]:
+    """
+    Write the outcome of a run in the Prometheus text format, for node_exporter's textfile collector.
+    fallback marks a failed extraction that was answered with the stored credentials.
+    The file is replaced atomically so the collector never reads a partial file.
+    """
+    labels = '{profile="%s"}' % profile_name.replace("\\", "\\\\").replace('"', '\\"')
+    metrics = [
+        ("nlm_auth_success", "Whether the last nlm auth run succeeded (1) or failed (0).", 1 if success else 0),
+        ("nlm_auth_duration_seconds", "Duration of the last nlm auth run in seconds.", round(duration, 3)),
+        ("nlm_auth_cookie_count", "Number of cookies captured by the last nlm auth run.", cookie_count),
+        ("nlm_auth_fallback", "Whether the last nlm auth run served stored credentials after a failed extraction (1) or not (0).", 1 if fallback else 0),
+    ]
+    lines = []
+    for name, help_text, value in metrics:
+        lines += [f"# HELP {name} {help_text}", f"# TYPE {name} gauge", f"{name}{labels} {value}"]
+
+    metrics_path = Path(path).expanduser()
+    temp_path = metrics_path.with_name(f".{metrics_path.name}.{os.getpid()}.tmp")
+    try:
+        temp_path.write_text("\n".join(lines) + "\n", encoding="utf-8")
+        os.replace(temp_path, metrics_path)
+    except OSError as e:
+        temp_path.unlink(missing_ok=True)
+        return Exception(f"Failed to write metrics to {metrics_path}: {e}")
+    return None
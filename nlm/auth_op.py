@@ -0,0 +1,57 @@
+"""
+1Password store for 'nlm auth --store op', using the 1Password CLI ('op').
+"""
+import json
+import os
+import shutil
+import subprocess
+import tempfile
+from typing import List, Optional
+
+from .auth_stores import CredentialStoreError, DEFAULT_OP_ITEM
+
+
+def _run_op(args: List[str]) -> subprocess.CompletedProcess:
+    """Run the 1Password CLI, capturing its output"""
+    return subprocess.run(["op"] + args, capture_output=True, text=True, timeout=60)
+
+
+def save_auth_to_1password(auth_token: str, cookies: str, profile_name: str = "Default",
+                           item: str = DEFAULT_OP_ITEM, vault: Optional[str] = None) -> None:
+    """
+    Create or update a 1Password item holding the credentials, using the 'op' CLI.
+    The values are passed in a private template file so they never appear on a command line.
+    """
+    if not shutil.which("op"):
+        raise CredentialStoreError("1Password CLI 'op' not found. Install it from https://developer.1password.com/docs/cli/ or use --store env.")
+    try:
+        if _run_op(["whoami"]).returncode != 0:
+            raise CredentialStoreError("Not signed in to 1Password. Run 'op signin' (or set OP_SERVICE_ACCOUNT_TOKEN) and try again.")
+        vault_args = ["--vault", vault] if vault else []
+        exists = _run_op(["item", "get", item, "--format", "json"] + vault_args).returncode == 0
+
+        template = {
+            "title": item,
+            "category": "API_CREDENTIAL",
+            "fields": [
+                {"id": "NLM_AUTH_TOKEN", "label": "NLM_AUTH_TOKEN", "type": "CONCEALED", "value": auth_token},
+                {"id": "NLM_COOKIES", "label": "NLM_COOKIES", "type": "CONCEALED", "value": cookies},
+                {"id": "NLM_BROWSER_PROFILE", "label": "NLM_BROWSER_PROFILE", "type": "STRING", "value": profile_name},
+            ],
+        }
+        fd, template_path = tempfile.mkstemp(prefix="nlm-op-", suffix=".json")
+        try:
+            with os.fdopen(fd, "w", encoding="utf-8") as f:
+                json.dump(template, f)
+            if exists:
+                command = ["item", "edit", item, "--template", template_path] + vault_args
+            else:
+                command = ["item", "create", "--template", template_path] + vault_args
+            completed = _run_op(command)
+        finally:
+            os.remove(template_path)
+    except (OSError, subprocess.SubprocessError) as e:
+        raise CredentialStoreError(f"Failed to run the 1Password CLI: {e}")
+
+    if completed.returncode != 0:
+        raise CredentialStoreError(f"1Password CLI failed to {'update' if exists else 'create'} item '{item}': {completed.stderr.strip()}")
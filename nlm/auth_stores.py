@@ -0,0 +1,12 @@
+"""
+Credential stores that 'nlm auth --store' can save to, shared by the store modules.
+"""
+
+# Where extracted credentials are stored: the env file, a 1Password item or the Windows Credential Manager
+CREDENTIAL_STORES = ["env", "op", "wincred"]
+DEFAULT_OP_ITEM = "NotebookLM"
+DEFAULT_WINCRED_TARGET = "nlm/NotebookLM"
+
+class CredentialStoreError(Exception):
+    """Raised when credentials cannot be written to the selected store."""
+    pass
@@ -0,0 +1,159 @@
+"""
+Windows Credential Manager store for 'nlm auth --store wincred'.
+
+The credentials are kept as generic credentials through the Win32 Cred* API via ctypes, so no
+extra package is needed. A generic credential holds at most 2560 bytes, less than a typical
+cookie string, so larger payloads are split over '<target>', '<target>#1', '<target>#2', ...
+"""
+import json
+import os
+from typing import List, Optional, Tuple
+
+from .auth_log import _log, _now_rfc3339
+from .auth_stores import CredentialStoreError, DEFAULT_WINCRED_TARGET
+
+CRED_TYPE_GENERIC = 1
+CRED_PERSIST_LOCAL_MACHINE = 2
+CRED_MAX_CREDENTIAL_BLOB_SIZE = 5 * 512
+ERROR_NOT_FOUND = 1168
+
+
+def _part_target(target: str, index: int) -> str:
+    return target if index == 0 else f"{target}#{index}"
+
+
+class _CredentialManager:
+    """Thin ctypes binding of CredReadW, CredWriteW and CredDeleteW"""
+
+    def __init__(self):
+        if os.name != "nt":
+            raise CredentialStoreError("Windows Credential Manager is only available on Windows; use --store env or --store op")
+        import ctypes
+        from ctypes import wintypes
+
+        class CREDENTIAL(ctypes.Structure):
+            _fields_ = [
+                ("Flags", wintypes.DWORD),
+                ("Type", wintypes.DWORD),
+                ("TargetName", wintypes.LPWSTR),
+                ("Comment", wintypes.LPWSTR),
+                ("LastWritten", wintypes.FILETIME),
+                ("CredentialBlobSize", wintypes.DWORD),
+                ("CredentialBlob", ctypes.POINTER(ctypes.c_ubyte)),
+                ("Persist", wintypes.DWORD),
+                ("AttributeCount", wintypes.DWORD),
+                ("Attributes", ctypes.c_void_p),
+                ("TargetAlias", wintypes.LPWSTR),
+                ("UserName", wintypes.LPWSTR),
+            ]
+
+        advapi32 = ctypes.WinDLL("advapi32", use_last_error=True)
+        advapi32.CredReadW.argtypes = [wintypes.LPCWSTR, wintypes.DWORD, wintypes.DWORD, ctypes.POINTER(ctypes.POINTER(CREDENTIAL))]
+        advapi32.CredReadW.restype = wintypes.BOOL
+        advapi32.CredWriteW.argtypes = [ctypes.POINTER(CREDENTIAL), wintypes.DWORD]
+        advapi32.CredWriteW.restype = wintypes.BOOL
+        advapi32.CredDeleteW.argtypes = [wintypes.LPCWSTR, wintypes.DWORD, wintypes.DWORD]
+        advapi32.CredDeleteW.restype = wintypes.BOOL
+        advapi32.CredFree.argtypes = [ctypes.c_void_p]
+        advapi32.CredFree.restype = None
+        self._ctypes = ctypes
+        self._credential = CREDENTIAL
+        self._advapi32 = advapi32
+
+    def _error(self, action: str, target: str) -> CredentialStoreError:
+        code = self._ctypes.get_last_error()
+        return CredentialStoreError(f"Failed to {action} Windows credential '{target}': {self._ctypes.FormatError(code).strip()} (error {code})")
+
+    def read(self, target: str) -> Optional[bytes]:
+        """Return the blob of a generic credential, or None if it does not exist"""
+        ctypes = self._ctypes
+        pointer = ctypes.POINTER(self._credential)()
+        if not self._advapi32.CredReadW(target, CRED_TYPE_GENERIC, 0, ctypes.byref(pointer)):
+            if ctypes.get_last_error() == ERROR_NOT_FOUND:
+                return None
+            raise self._error("read", target)
+        try:
+            credential = pointer.contents
+            return ctypes.string_at(credential.CredentialBlob, credential.CredentialBlobSize)
+        finally:
+            self._advapi32.CredFree(pointer)
+
+    def write(self, target: str, blob: bytes, user_name: str, comment: str) -> None:
+        """Create or replace a generic credential"""
+        ctypes = self._ctypes
+        buffer = ctypes.create_string_buffer(blob, len(blob))
+        credential = self._credential()
+        credential.Type = CRED_TYPE_GENERIC
+        credential.TargetName = target
+        credential.Comment = comment
+        credential.UserName = user_name
+        credential.CredentialBlobSize = len(blob)
+        credential.CredentialBlob = ctypes.cast(buffer, ctypes.POINTER(ctypes.c_ubyte))
+        credential.Persist = CRED_PERSIST_LOCAL_MACHINE
+        if not self._advapi32.CredWriteW(ctypes.byref(credential), 0):
+            raise self._error("write", target)
+
+    def delete(self, target: str) -> bool:
+        """Delete a generic credential, returning False if it did not exist"""
+        if self._advapi32.CredDeleteW(target, CRED_TYPE_GENERIC, 0):
+            return True
+        if self._ctypes.get_last_error() == ERROR_NOT_FOUND:
+            return False
+        raise self._error("delete", target)
+
+
+def save_auth_to_wincred(auth_token: str, cookies: str, profile_name: str = "Default", target: str = "") -> bool:
+    """
+    Create or update the credentials under target in the Windows Credential Manager.
+    Returns True if they replaced existing credentials.
+    """
+    target = target or DEFAULT_WINCRED_TARGET
+    manager = _CredentialManager()
+    payload = json.dumps({
+        "auth_token": auth_token,
+        "cookies": cookies,
+        "profile_name": profile_name,
+        "extracted_at": _now_rfc3339(),
+    }, separators=(",", ":")).encode("utf-8")
+    parts: List[bytes] = [payload[i:i + CRED_MAX_CREDENTIAL_BLOB_SIZE] for i in range(0, len(payload), CRED_MAX_CREDENTIAL_BLOB_SIZE)]
+
+    existed = manager.read(target) is not None
+    for index, part in enumerate(parts):
+        manager.write(_part_target(target, index), part, profile_name, f"nlm auth credentials, part {index + 1} of {len(parts)}")
+    # Drop parts left over from an earlier, longer payload so reading stops at the right one
+    index = len(parts)
+    while manager.delete(_part_target(target, index)):
+        index += 1
+    return existed
+
+
+def load_auth_from_wincred(target: str = "") -> Optional[Tuple[str, str]]:
+    """Read the credentials saved under target, or return None if there are none"""
+    target = target or DEFAULT_WINCRED_TARGET
+    manager = _CredentialManager()
+    parts = []
+    while True:
+        part = manager.read(_part_target(target, len(parts)))
+        if part is None:
+            break
+        parts.append(part)
+    if not parts:
+        return None
+    try:
+        data = json.loads(b"".join(parts).decode("utf-8"))
+        return data["auth_token"], data["cookies"]
+    except (ValueError, KeyError, TypeError) as e:
+        raise CredentialStoreError(f"Windows credential '{target}' does not hold nlm credentials: {e}")
+
+
+def load_wincred_credentials(target: Optional[str] = None) -> Tuple[Optional[str], Optional[str]]:
+    """
+    Load credentials saved with --store wincred from the Windows Credential Manager, using target,
+    then $NLM_WINCRED_TARGET, then the default target. Returns (None, None) if there are none.
+    """
+    target = target or os.environ.get("NLM_WINCRED_TARGET") or DEFAULT_WINCRED_TARGET
+    try:
+        return load_auth_from_wincred(target) or (None, None)
+    except CredentialStoreError as e:
+        _log(f"Error reading Windows credential '{target}': {e}", "error")
+        return None, None
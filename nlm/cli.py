@@ -6,7 +6,7 @@ import json
 from pathlib import Path
 
 from .api.client import Client
-from .auth import handle_auth, load_stored_env
+from .auth import handle_auth, load_stored_env, load_wincred_credentials, exit_code_for_error
 
 
 class ServiceCLI:
@@ -22,6 +22,9 @@ class ServiceCLI:
         # Try to load from stored env
         if not self.auth_token or not self.cookies:
             auth_token, cookies = load_stored_env()
+            if not auth_token and os.name == "nt":
+                # Saved with 'nlm auth --store wincred' instead of the env file
+                auth_token, cookies = load_wincred_credentials()
             if auth_token:
                 self.auth_token = auth_token
             if cookies:
@@ -49,8 +52,8 @@ class ServiceCLI:
         if cmd == "auth":
             auth_token, cookies, err = handle_auth(args, self.debug)
             if err:
-                print(f"Error: {err}")
-                sys.exit(1)
+                print(f"Error: {err}", file=sys.stderr)
+                sys.exit(exit_code_for_error(err))
             self.auth_token = auth_token
             self.cookies = cookies
             return
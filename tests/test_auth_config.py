@@ -0,0 +1,133 @@
+import argparse
+import json
+import os
+import tempfile
+import unittest
+from pathlib import Path
+from unittest import mock
+
+from nlm.auth import _parse_auth_args
+from nlm.auth_config import _comma_list, apply_config, explicit_dests, load_config
+
+try:
+    import tomllib
+except ImportError:
+    tomllib = None
+
+
+def _parser():
+    parser = argparse.ArgumentParser()
+    parser.add_argument("profiles", nargs="*")
+    parser.add_argument("--poll-timeout", type=float, default=30.0)
+    parser.add_argument("--print", dest="print_value", choices=["token", "cookies"])
+    parser.add_argument("--cookie-include", type=_comma_list)
+    parser.add_argument("--chrome-flag", action="append")
+    parser.add_argument("--visible", action="store_true")
+    return parser
+
+
+class LoadConfigTest(unittest.TestCase):
+    def setUp(self):
+        self.dir = tempfile.TemporaryDirectory()
+
+    def tearDown(self):
+        self.dir.cleanup()
+
+    def _load(self, name, text):
+        path = Path(self.dir.name, name)
+        path.write_text(text, encoding="utf-8")
+        with mock.patch.dict(os.environ, {"NLM_CONFIG": str(path)}):
+            return load_config()
+
+    def test_json(self):
+        path, config = self._load("config.json", '{"poll-timeout": 10, "profile": "Work"}')
+        self.assertEqual(path.name, "config.json")
+        self.assertEqual(config, {"poll-timeout": 10, "profile": "Work"})
+
+    @unittest.skipIf(tomllib is None, "TOML needs Python 3.11")
+    def test_toml(self):
+        _, config = self._load("config.toml", 'poll-timeout = 10\nchrome-flag = ["--mute-audio"]\n')
+        self.assertEqual(config, {"poll-timeout": 10, "chrome-flag": ["--mute-audio"]})
+
+    def test_not_an_object(self):
+        with self.assertRaisesRegex(ValueError, "expected an object"):
+            self._load("config.json", "[1, 2]")
+
+    def test_missing_file(self):
+        with mock.patch.dict(os.environ, {"NLM_CONFIG": os.path.join(self.dir.name, "missing.json")}):
+            with self.assertRaises(FileNotFoundError):
+                load_config()
+
+
+class ApplyConfigTest(unittest.TestCase):
+    def test_settings_become_defaults(self):
+        parser = _parser()
+        builtin = apply_config(parser, {"poll_timeout": "12.5", "print": "cookies", "cookie-include": ["SID", "HSID"],
+                                        "chrome-flag": "--mute-audio", "visible": True, "profile": "Work"})
+        self.assertEqual(builtin, {"poll_timeout": 30.0, "print_value": None, "cookie_include": None,
+                                   "chrome_flag": None, "visible": False, "profiles": []})
+        parsed = parser.parse_args([])
+        self.assertEqual(parsed.poll_timeout, 12.5)
+        self.assertEqual(parsed.print_value, "cookies")
+        self.assertEqual(parsed.cookie_include, ["SID", "HSID"])
+        self.assertEqual(parsed.chrome_flag, ["--mute-audio"])
+        self.assertTrue(parsed.visible)
+        self.assertEqual(parsed.profiles, ["Work"])
+
+    def test_command_line_wins(self):
+        parser = _parser()
+        apply_config(parser, {"poll-timeout": 12})
+        self.assertEqual(parser.parse_args(["--poll-timeout", "5"]).poll_timeout, 5.0)
+
+    def test_invalid_settings(self):
+        for config, message in (
+            ({"no-such-option": 1}, "unknown option"),
+            ({"poll-timeout": "soon"}, "'poll-timeout'"),
+            ({"print": "everything"}, "must be one of"),
+            ({"visible": "yes"}, "true or false"),
+            ({"profile": 3}, "profile name"),
+        ):
+            with self.assertRaisesRegex(ValueError, message, msg=config):
+                apply_config(_parser(), config)
+
+    def test_environment_variable_wins(self):
+        parser = _parser()
+        with mock.patch.dict(os.environ, {"NLM_BROWSER_PROFILE": "Env"}):
+            self.assertEqual(apply_config(parser, {"profile": "Work"}), {})
+
+    def test_explicit_dests(self):
+        parser = _parser()
+        apply_config(parser, {"poll-timeout": 12, "profile": "Work"})
+        self.assertEqual(explicit_dests(parser, ["--print", "token"]), {"print_value"})
+        self.assertEqual(explicit_dests(parser, ["Home", "--chrome-flag=--mute-audio"]), {"profiles", "chrome_flag"})
+
+
+class ParseAuthArgsConfigTest(unittest.TestCase):
+    def setUp(self):
+        self.dir = tempfile.TemporaryDirectory()
+        self.environ = mock.patch.dict(os.environ, {"NLM_CONFIG": os.path.join(self.dir.name, "config.json")})
+        self.environ.start()
+        for name in ("NLM_BROWSER_PROFILE", "NLM_ENV_PATH", "NLM_CHROME_PATH"):
+            os.environ.pop(name, None)
+
+    def tearDown(self):
+        self.environ.stop()
+        self.dir.cleanup()
+
+    def _parse(self, config, args):
+        Path(os.environ["NLM_CONFIG"]).write_text(json.dumps(config), encoding="utf-8")
+        return _parse_auth_args(args)
+
+    def test_config_applies(self):
+        parsed = self._parse({"format": "yaml", "jobs": 3}, [])
+        self.assertEqual((parsed.format, parsed.jobs), ("yaml", 3))
+
+    def test_exclusive_flag_drops_the_configured_option(self):
+        parsed = self._parse({"format": "yaml", "jobs": 3}, ["--out", "json:-"])
+        self.assertIsNone(parsed.format)
+        self.assertEqual(parsed.jobs, 3)
+        self.assertEqual([(target.kind, target.dest) for target in parsed.out], [("json", "-")])
+
+
+if __name__ == "__main__":
+    unittest.main()
@@ -0,0 +1,74 @@
+import os
+import stat
+import tempfile
+import unittest
+from pathlib import Path
+
+from nlm.auth_env import _parse_env_line, compare_with_stored, load_stored_env, save_auth_to_env
+
+
+class ParseEnvLineTest(unittest.TestCase):
+    def test_plain_value(self):
+        self.assertEqual(_parse_env_line("NLM_AUTH_TOKEN=abc"), ("NLM_AUTH_TOKEN", "abc", ""))
+
+    def test_export_prefix(self):
+        self.assertEqual(_parse_env_line("export NLM_AUTH_TOKEN=abc"), ("NLM_AUTH_TOKEN", "abc", ""))
+
+    def test_quoted_values_keep_equals_and_hashes(self):
+        self.assertEqual(_parse_env_line('NLM_COOKIES="SID=1; HSID=#2"'), ("NLM_COOKIES", "SID=1; HSID=#2", ""))
+        self.assertEqual(_parse_env_line("NLM_COOKIES='SID=1'"), ("NLM_COOKIES", "SID=1", ""))
+
+    def test_trailing_comment(self):
+        self.assertEqual(_parse_env_line('KEY="value" # note'), ("KEY", "value", "# note"))
+        self.assertEqual(_parse_env_line("KEY=value # note"), ("KEY", "value", "# note"))
+
+    def test_blank_and_comment_lines(self):
+        for line in ("", "   ", "# comment", "no equals sign"):
+            self.assertIsNone(_parse_env_line(line), line)
+
+
+class EnvFileTest(unittest.TestCase):
+    def setUp(self):
+        self.dir = tempfile.TemporaryDirectory()
+        self.env_path = os.path.join(self.dir.name, "env")
+
+    def tearDown(self):
+        self.dir.cleanup()
+
+    def test_save_and_load(self):
+        save_auth_to_env("token", "SID=1; HSID=2", "Work", self.env_path)
+        self.assertEqual(load_stored_env(self.env_path), ("token", "SID=1; HSID=2"))
+
+    def test_save_keeps_other_lines(self):
+        Path(self.env_path).write_text("# mine\nOTHER=1\nNLM_AUTH_TOKEN=old\n", encoding="utf-8")
+        save_auth_to_env("new", "SID=1", env_path=self.env_path)
+        text = Path(self.env_path).read_text(encoding="utf-8")
+        self.assertIn("# mine\nOTHER=1\n", text)
+        self.assertIn('NLM_AUTH_TOKEN="new"', text)
+        self.assertNotIn("old", text)
+
+    @unittest.skipIf(os.name == "nt", "POSIX permissions")
+    def test_private_permissions(self):
+        env_path = os.path.join(self.dir.name, "a", "b", "env")
+        save_auth_to_env("token", "SID=1", env_path=env_path)
+        for directory in (os.path.join(self.dir.name, "a"), os.path.join(self.dir.name, "a", "b")):
+            self.assertEqual(stat.S_IMODE(os.stat(directory).st_mode), 0o700, directory)
+        os.chmod(env_path, 0o644)
+        save_auth_to_env("token", "SID=1", env_path=env_path)
+        self.assertEqual(stat.S_IMODE(os.stat(env_path).st_mode), 0o600)
+
+    def test_compare_with_nothing_stored(self):
+        self.assertEqual(compare_with_stored("token", "SID=1", self.env_path), ["nothing stored yet"])
+
+    def test_compare_identical(self):
+        save_auth_to_env("token", "SID=1; HSID=2", env_path=self.env_path)
+        self.assertEqual(compare_with_stored("token", "HSID=2; SID=1", self.env_path), [])
+
+    def test_compare_reports_each_kind_of_change(self):
+        save_auth_to_env("token", "SID=1; HSID=2; SSID=3", env_path=self.env_path)
+        changes = compare_with_stored("other", "SID=9; HSID=2; APISID=4", self.env_path)
+        self.assertEqual(changes, ["token", "cookies added: APISID", "cookies removed: SSID", "cookies changed: SID"])
+
+
+if __name__ == "__main__":
+    unittest.main()
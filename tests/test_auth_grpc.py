@@ -0,0 +1,71 @@
+import struct
+import unittest
+
+from nlm.auth import AuthResult
+from nlm.auth_grpc import _decode_varint, _encode_varint, decode_extract_request, encode_auth_result
+
+
+def _field(number, wire_type):
+    return _encode_varint(number << 3 | wire_type)
+
+
+def _string(number, value):
+    data = value.encode("utf-8")
+    return _field(number, 2) + _encode_varint(len(data)) + data
+
+
+class VarintTest(unittest.TestCase):
+    def test_known_encodings(self):
+        self.assertEqual(_encode_varint(0), b"\x00")
+        self.assertEqual(_encode_varint(1), b"\x01")
+        self.assertEqual(_encode_varint(127), b"\x7f")
+        self.assertEqual(_encode_varint(128), b"\x80\x01")
+        self.assertEqual(_encode_varint(300), b"\xac\x02")
+
+    def test_round_trip(self):
+        for value in (0, 1, 127, 128, 300, 16384, 2**32, 2**63 - 1):
+            data = b"\xff" + _encode_varint(value) + b"\x00"
+            self.assertEqual(_decode_varint(data, 1), (value, len(data) - 1), value)
+
+    def test_truncated(self):
+        with self.assertRaisesRegex(ValueError, "truncated varint"):
+            _decode_varint(b"\x80\x80", 0)
+
+
+class MessageTest(unittest.TestCase):
+    def test_encode_auth_result_skips_empty_fields(self):
+        result = AuthResult(auth_token="tok", cookies="SID=1", profile_name="Work", browser="edge", version="1",
+                            extracted_at="", account_email="", authorization="", at_token="xsrf")
+        expected = (_string(1, "tok") + _string(2, "SID=1") + _string(3, "Work") + _string(4, "edge")
+                    + _string(5, "1") + _string(9, "xsrf"))
+        self.assertEqual(encode_auth_result(result), expected)
+
+    def test_encode_long_field(self):
+        cookies = "x" * 200
+        encoded = encode_auth_result(AuthResult(auth_token="", cookies=cookies, profile_name="", browser="", version=""))
+        self.assertEqual(encoded, b"\x12\xc8\x01" + cookies.encode("ascii"))
+
+    def test_decode_extract_request(self):
+        data = _string(1, "Work") + _string(2, "brave") + _field(3, 1) + struct.pack("<d", 12.5)
+        self.assertEqual(decode_extract_request(data), {"profile": "Work", "browser": "brave", "timeout_seconds": 12.5})
+
+    def test_decode_empty_request(self):
+        self.assertEqual(decode_extract_request(b""), {"profile": "", "browser": "", "timeout_seconds": 0.0})
+
+    def test_decode_skips_unknown_fields(self):
+        data = _field(7, 0) + _encode_varint(300) + _string(8, "ignored") + _field(9, 5) + b"\x00" * 4 + _string(1, "Work")
+        self.assertEqual(decode_extract_request(data)["profile"], "Work")
+
+    def test_decode_truncated(self):
+        with self.assertRaisesRegex(ValueError, "truncated message"):
+            decode_extract_request(_field(1, 2) + _encode_varint(10) + b"Work")
+        with self.assertRaisesRegex(ValueError, "truncated message"):
+            decode_extract_request(_field(3, 1) + b"\x00" * 4)
+
+    def test_decode_unsupported_wire_type(self):
+        with self.assertRaisesRegex(ValueError, "unsupported wire type 3"):
+            decode_extract_request(_field(1, 3))
+
+
+if __name__ == "__main__":
+    unittest.main()
@@ -0,0 +1,65 @@
+import argparse
+import unittest
+
+from nlm.auth import OutputTarget, _output_target
+from nlm.auth_errors import (
+    EXIT_BROWSER_LAUNCH_FAILED, EXIT_INTERRUPTED, EXIT_LOGIN_REQUIRED, EXIT_PARTIAL, EXIT_PROFILE_NOT_FOUND, EXIT_TIMEOUT,
+    EXIT_UNKNOWN, AuthInterruptedError, BrowserLaunchError, KeyringLockedError, LoginRequiredError, OverallTimeoutError,
+    PartialAuthError, StaleCredentialsError, exit_code_for_error,
+)
+
+
+class OutputTargetTest(unittest.TestCase):
+    def test_kind_and_destination(self):
+        self.assertEqual(_output_target("json:creds.json"), OutputTarget("json", "creds.json"))
+        self.assertEqual(_output_target("DOTENV:-"), OutputTarget("dotenv", "-"))
+        self.assertEqual(_output_target("socket:/run/nlm.sock"), OutputTarget("socket", "/run/nlm.sock"))
+
+    def test_destination_keeps_colons(self):
+        self.assertEqual(_output_target("file:C:\\creds.json"), OutputTarget("file", "C:\\creds.json"))
+
+    def test_stores_default_their_destination(self):
+        for kind in ("env", "op", "wincred"):
+            self.assertEqual(_output_target(f"{kind}:"), OutputTarget(kind, ""))
+
+    def test_formats_need_a_destination(self):
+        with self.assertRaisesRegex(argparse.ArgumentTypeError, "json:- for stdout"):
+            _output_target("json:")
+        with self.assertRaisesRegex(argparse.ArgumentTypeError, "socket path"):
+            _output_target("socket:")
+
+    def test_invalid_targets(self):
+        for value in ("json", "creds.json", "xml:out.xml"):
+            with self.assertRaisesRegex(argparse.ArgumentTypeError, "expected KIND:DEST", msg=value):
+                _output_target(value)
+
+    def test_keychain_is_rejected(self):
+        with self.assertRaisesRegex(argparse.ArgumentTypeError, "no keychain target; use op:"):
+            _output_target("keychain:NotebookLM")
+
+
+class ExitCodeTest(unittest.TestCase):
+    def test_error_types(self):
+        cases = [
+            (AuthInterruptedError("interrupted"), EXIT_INTERRUPTED),
+            (PartialAuthError("partial"), EXIT_PARTIAL),
+            (LoginRequiredError("login"), EXIT_LOGIN_REQUIRED),
+            (BrowserLaunchError("launch"), EXIT_BROWSER_LAUNCH_FAILED),
+            (FileNotFoundError("profile"), EXIT_PROFILE_NOT_FOUND),
+            (TimeoutError("timeout"), EXIT_TIMEOUT),
+            (OverallTimeoutError("overall"), EXIT_TIMEOUT),
+            (KeyringLockedError("keyring"), EXIT_UNKNOWN),
+            (ValueError("other"), EXIT_UNKNOWN),
+        ]
+        for err, code in cases:
+            self.assertEqual(exit_code_for_error(err), code, repr(err))
+
+    def test_cause_is_followed(self):
+        err = StaleCredentialsError("stored credentials used")
+        err.__cause__ = TimeoutError("page load timed out")
+        self.assertEqual(exit_code_for_error(err), EXIT_TIMEOUT)
+        self.assertEqual(exit_code_for_error(StaleCredentialsError("no cause")), EXIT_UNKNOWN)
+
+
+if __name__ == "__main__":
+    unittest.main()
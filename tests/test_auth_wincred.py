@@ -0,0 +1,68 @@
+import json
+import unittest
+from unittest import mock
+
+from nlm import auth_wincred
+from nlm.auth_stores import CredentialStoreError
+from nlm.auth_wincred import CRED_MAX_CREDENTIAL_BLOB_SIZE, load_auth_from_wincred, save_auth_to_wincred
+
+
+class FakeCredentialManager:
+    """In-memory stand-in for the Win32 Cred* binding"""
+    credentials = {}
+
+    def read(self, target):
+        return self.credentials.get(target, (None,))[0]
+
+    def write(self, target, blob, user_name, comment):
+        assert len(blob) <= CRED_MAX_CREDENTIAL_BLOB_SIZE
+        self.credentials[target] = (blob, user_name, comment)
+
+    def delete(self, target):
+        return self.credentials.pop(target, None) is not None
+
+
+class WincredTest(unittest.TestCase):
+    def setUp(self):
+        FakeCredentialManager.credentials = {}
+        patcher = mock.patch.object(auth_wincred, "_CredentialManager", FakeCredentialManager)
+        patcher.start()
+        self.addCleanup(patcher.stop)
+
+    def test_small_payload_uses_one_credential(self):
+        self.assertFalse(save_auth_to_wincred("tok", "SID=1", "Work", "nlm/Test"))
+        self.assertEqual(list(FakeCredentialManager.credentials), ["nlm/Test"])
+        blob, user_name, comment = FakeCredentialManager.credentials["nlm/Test"]
+        self.assertEqual(json.loads(blob)["profile_name"], "Work")
+        self.assertEqual((user_name, comment), ("Work", "nlm auth credentials, part 1 of 1"))
+        self.assertEqual(load_auth_from_wincred("nlm/Test"), ("tok", "SID=1"))
+
+    def test_large_payload_is_split(self):
+        cookies = "; ".join(f"COOKIE{i}={'v' * 100}" for i in range(60))
+        save_auth_to_wincred("tok", cookies, target="nlm/Test")
+        self.assertEqual(sorted(FakeCredentialManager.credentials), ["nlm/Test", "nlm/Test#1", "nlm/Test#2"])
+        self.assertEqual(len(FakeCredentialManager.credentials["nlm/Test"][0]), CRED_MAX_CREDENTIAL_BLOB_SIZE)
+        self.assertEqual(load_auth_from_wincred("nlm/Test"), ("tok", cookies))
+
+    def test_shorter_payload_drops_leftover_parts(self):
+        save_auth_to_wincred("tok", "x" * 6000, target="nlm/Test")
+        self.assertTrue(save_auth_to_wincred("tok2", "SID=1", target="nlm/Test"))
+        self.assertEqual(list(FakeCredentialManager.credentials), ["nlm/Test"])
+        self.assertEqual(load_auth_from_wincred("nlm/Test"), ("tok2", "SID=1"))
+
+    def test_default_target(self):
+        save_auth_to_wincred("tok", "SID=1")
+        self.assertEqual(list(FakeCredentialManager.credentials), ["nlm/NotebookLM"])
+        self.assertEqual(load_auth_from_wincred(), ("tok", "SID=1"))
+
+    def test_missing_credentials(self):
+        self.assertIsNone(load_auth_from_wincred("nlm/Missing"))
+
+    def test_foreign_credential(self):
+        FakeCredentialManager.credentials["nlm/Other"] = (b"not json", "someone", "")
+        with self.assertRaisesRegex(CredentialStoreError, "does not hold nlm credentials"):
+            load_auth_from_wincred("nlm/Other")
+
+
+if __name__ == "__main__":
+    unittest.main()